@@ -1,14 +1,22 @@
 package ca
 
 import (
+	"bytes"
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
 	"io"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/smallstep/certificates/cas/apiv1"
+	kmsapi "github.com/smallstep/certificates/kms/apiv1"
 	"github.com/smallstep/certificates/pki"
 	"github.com/smallstep/cli/command"
 	"github.com/smallstep/cli/crypto/pemutil"
@@ -16,8 +24,281 @@ import (
 	"github.com/smallstep/cli/ui"
 	"github.com/smallstep/cli/utils"
 	"github.com/urfave/cli"
+	"go.step.sm/cli-utils/step"
 )
 
+// defaultOnboardingURL is the public smallstep onboarding service used when
+// --onboarding-url is not set.
+const defaultOnboardingURL = "https://api.smallstep.com/onboarding"
+
+// onboardingProvisioner is the provisioner section of the onboarding schema.
+type onboardingProvisioner struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// onboardingPayload is the versioned schema returned by the onboarding
+// service (or provided locally via --config) describing the PKI parameters
+// to use for an unattended `step ca init`.
+type onboardingPayload struct {
+	Name           string                 `json:"name"`
+	DNS            string                 `json:"dns"`
+	Address        string                 `json:"address"`
+	SSH            *bool                  `json:"ssh,omitempty"`
+	Provisioner    *onboardingProvisioner `json:"provisioner,omitempty"`
+	DeploymentType string                 `json:"deploymentType,omitempty"`
+}
+
+// validate makes sure the required fields of the onboarding schema are set.
+func (p *onboardingPayload) validate() error {
+	switch {
+	case p.Name == "":
+		return errs.NewError("onboarding configuration is missing the %q property", "name")
+	case p.DNS == "":
+		return errs.NewError("onboarding configuration is missing the %q property", "dns")
+	case p.Address == "":
+		return errs.NewError("onboarding configuration is missing the %q property", "address")
+	default:
+		return nil
+	}
+}
+
+// fetchOnboardingPayload retrieves the PKI parameters for the given
+// onboarding token from the onboarding service at onboardingURL.
+func fetchOnboardingPayload(onboardingURL, token string) (*onboardingPayload, error) {
+	u := strings.TrimSuffix(onboardingURL, "/") + "/" + token
+	resp, err := http.Get(u)
+	if err != nil {
+		return nil, errs.Wrap(err, "error fetching onboarding configuration")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, errs.NewError("error fetching onboarding configuration: %s", resp.Status)
+	}
+
+	var payload onboardingPayload
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, errs.Wrap(err, "error parsing onboarding configuration")
+	}
+	if err := payload.validate(); err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
+
+// readOnboardingPayload reads the PKI parameters from a local file using the
+// same schema as the onboarding service, so that `step ca init --config` can
+// be driven without a TTY.
+func readOnboardingPayload(filename string) (*onboardingPayload, error) {
+	b, err := utils.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload onboardingPayload
+	if err := json.Unmarshal(b, &payload); err != nil {
+		return nil, errs.Wrap(err, "error parsing %s", filename)
+	}
+	if err := payload.validate(); err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
+
+// sendOnboardingFingerprint reports the generated root certificate
+// fingerprint back to the onboarding service so that a paired UI/CLI flow
+// can complete setup.
+func sendOnboardingFingerprint(onboardingURL, token, fingerprint string) error {
+	body, err := json.Marshal(struct {
+		Fingerprint string `json:"fingerprint"`
+	}{Fingerprint: fingerprint})
+	if err != nil {
+		return errs.Wrap(err, "error marshaling onboarding request")
+	}
+
+	u := strings.TrimSuffix(onboardingURL, "/") + "/" + token + "/done"
+	resp, err := http.Post(u, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return errs.Wrap(err, "error reporting the root fingerprint to the onboarding service")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return errs.NewError("error reporting the root fingerprint to the onboarding service: %s", resp.Status)
+	}
+	return nil
+}
+
+// certificateFingerprint returns the hex-encoded SHA-256 fingerprint of cert.
+func certificateFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// manifestSchemaVersion is the current version of the pki-manifest.json
+// schema written by `step ca init` and read by `step ca init --from-config`.
+const manifestSchemaVersion = 1
+
+// pkiManifest is a diffable, non-secret record of the parameters chosen for
+// a `step ca init` run. It is written to pki-manifest.json next to ca.json
+// and can be replayed with `step ca init --from-config`. Passwords and
+// credentials files are never written here. CloudCAS settings and the
+// --root/--key files are not recorded.
+type pkiManifest struct {
+	SchemaVersion int `json:"schemaVersion"`
+
+	DeploymentType string `json:"deploymentType,omitempty"`
+	Name           string `json:"name,omitempty"`
+	Organization   string `json:"organization,omitempty"`
+	Resource       string `json:"resource,omitempty"`
+	DNSNames       string `json:"dnsNames,omitempty"`
+	Address        string `json:"address,omitempty"`
+	Provisioner    string `json:"provisioner,omitempty"`
+	SSH            bool   `json:"ssh,omitempty"`
+	NoDB           bool   `json:"noDB,omitempty"`
+	Helm           bool   `json:"helm,omitempty"`
+	WithCaURL      string `json:"withCaUrl,omitempty"`
+
+	RA                string `json:"ra,omitempty"`
+	Issuer            string `json:"issuer,omitempty"`
+	IssuerFingerprint string `json:"issuerFingerprint,omitempty"`
+	IssuerProvisioner string `json:"issuerProvisioner,omitempty"`
+
+	VaultPKIMount   string `json:"vaultPkiMount,omitempty"`
+	VaultPKIRole    string `json:"vaultPkiRole,omitempty"`
+	VaultAuthMethod string `json:"vaultAuthMethod,omitempty"`
+
+	KMS                string `json:"kms,omitempty"`
+	KMSURI             string `json:"kmsUri,omitempty"`
+	RootKeyURI         string `json:"rootKeyUri,omitempty"`
+	IntermediateKeyURI string `json:"intermediateKeyUri,omitempty"`
+	SSHHostKeyURI      string `json:"sshHostKeyUri,omitempty"`
+	SSHUserKeyURI      string `json:"sshUserKeyUri,omitempty"`
+
+	// SCEPProvisioners and ACMEProvisioners only record the --with-scep and
+	// --with-acme values that were given, minus the SCEP challenge, which is
+	// a secret and must be re-supplied on replay.
+	SCEPProvisioners []string `json:"scepProvisioners,omitempty"`
+	ACMEProvisioners []string `json:"acmeProvisioners,omitempty"`
+}
+
+// readPKIManifest reads and validates a pki-manifest.json written by a
+// previous `step ca init` run.
+func readPKIManifest(filename string) (*pkiManifest, error) {
+	b, err := utils.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var m pkiManifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, errs.Wrap(err, "error parsing %s", filename)
+	}
+	if m.SchemaVersion != manifestSchemaVersion {
+		return nil, errs.NewError("%s has schema version %d, but this version of step only supports version %d", filename, m.SchemaVersion, manifestSchemaVersion)
+	}
+	return &m, nil
+}
+
+// writePKIManifest writes the pki-manifest.json describing a successful
+// `step ca init` run next to the generated ca.json.
+func writePKIManifest(dir string, m *pkiManifest) error {
+	m.SchemaVersion = manifestSchemaVersion
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return errs.Wrap(err, "error marshaling pki-manifest.json")
+	}
+	if err := utils.WriteFile(filepath.Join(dir, "pki-manifest.json"), b, 0600); err != nil {
+		return errs.FileError(err, filepath.Join(dir, "pki-manifest.json"))
+	}
+	return nil
+}
+
+// manifestGet reads a string field out of a possibly nil *pkiManifest.
+func manifestGet(m *pkiManifest, get func(*pkiManifest) string) string {
+	if m == nil {
+		return ""
+	}
+	return get(m)
+}
+
+// manifestGetSlice reads a string slice field out of a possibly nil
+// *pkiManifest.
+func manifestGetSlice(m *pkiManifest, get func(*pkiManifest) []string) []string {
+	if m == nil {
+		return nil
+	}
+	return get(m)
+}
+
+// stringSliceOrManifest returns the given repeatable flag's values if it was
+// set on the command line, falling back to the values recorded in a
+// replayed pki-manifest.json.
+func stringSliceOrManifest(ctx *cli.Context, flag string, manifestValues []string) []string {
+	if ctx.IsSet(flag) || len(manifestValues) == 0 {
+		return ctx.StringSlice(flag)
+	}
+	return manifestValues
+}
+
+// flagOrManifest returns the given flag's value if it was set on the command
+// line, falling back to the value recorded in a replayed pki-manifest.json.
+// It returns an error if an explicit flag contradicts a non-empty value
+// already committed to the manifest, so that --from-config replays fail
+// loudly instead of silently diverging from the recorded PKI.
+func flagOrManifest(ctx *cli.Context, flag, manifestValue string) (string, error) {
+	v := ctx.String(flag)
+	if ctx.IsSet(flag) {
+		if manifestValue != "" && manifestValue != v {
+			return "", errs.NewError("--%s=%s conflicts with the value recorded in --from-config (%s)", flag, v, manifestValue)
+		}
+		return v, nil
+	}
+	if manifestValue != "" {
+		return manifestValue, nil
+	}
+	return v, nil
+}
+
+// splitDNSNames splits a comma/space separated list of DNS names or IP
+// addresses into a clean slice.
+func splitDNSNames(names string) []string {
+	names = strings.Replace(names, " ", ",", -1)
+	var dnsNames []string
+	for _, name := range strings.Split(names, ",") {
+		if len(name) == 0 {
+			continue
+		}
+		dnsNames = append(dnsNames, strings.TrimSpace(name))
+	}
+	return dnsNames
+}
+
+// kmsTypes are the kms types currently supported by `step ca init --kms`.
+var kmsTypes = []string{
+	"pkcs11", "cloudkms", "azurekms", "awskms", "yubihsm", "sshagentkms",
+}
+
+func isValidKMS(v string) bool {
+	for _, t := range kmsTypes {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// vaultAuthMethods are the Vault authentication methods supported by
+// `step ca init --ra=vault`.
+var vaultAuthMethods = []string{"token", "approle", "kubernetes", "jwt"}
+
+func isValidVaultAuthMethod(v string) bool {
+	for _, m := range vaultAuthMethods {
+		if v == m {
+			return true
+		}
+	}
+	return false
+}
+
 func initCommand() cli.Command {
 	return cli.Command{
 		Name:   "init",
@@ -27,7 +308,7 @@ func initCommand() cli.Command {
 [**--root**=<file>] [**--key**=<file>] [**--pki**] [**--ssh**] [**--name**=<name>]
 [**--dns**=<dns>] [**--address**=<address>] [**--provisioner**=<name>]
 [**--provisioner-password-file**=<file>] [**--password-file**=<file>]
-[**--with-ca-url**=<url>] [**--no-db**]`,
+[**--with-ca-url**=<url>] [**--no-db**] [**--kms**=<kms>] [**--kms-uri**=<uri>]`,
 		Description: `**step ca init** command initializes a public key infrastructure (PKI) to be
  used by the Certificate Authority.`,
 		Flags: []cli.Flag{
@@ -98,7 +379,7 @@ func initCommand() cli.Command {
 			},
 			cli.StringFlag{
 				Name:  "ra",
-				Usage: `The registration authority <name> to use. Currently "StepCAS" and "CloudCAS" are supported.`,
+				Usage: `The registration authority <name> to use. Currently "StepCAS", "CloudCAS" and "VaultCAS" (or its alias "vault") are supported.`,
 			},
 			cli.StringFlag{
 				Name: "issuer",
@@ -109,7 +390,10 @@ to, e.g https://ca.smallstpe.com:9000
 
 : If CloudCAS is used, this flag should be the resource name of the
 intermediate certificate to use. This has the format
-'projects/\\*/locations/\\*/caPools/\\*/certificateAuthorities/\\*'.`,
+'projects/\\*/locations/\\*/caPools/\\*/certificateAuthorities/\\*'.
+
+: If VaultCAS is used, this flag should be the <address> of the Vault
+server, e.g https://vault.example.com`,
 			},
 			cli.StringFlag{
 				Name: "issuer-fingerprint",
@@ -139,10 +423,264 @@ Cloud.`,
 				Name:  "no-db",
 				Usage: `Generate a CA configuration without the DB stanza. No persistence layer.`,
 			},
+			cli.StringFlag{
+				Name: "kms",
+				Usage: `The <name> of the KMS used to generate and protect the CA keys. Options are:
+    **pkcs11**
+    :  Use a PKCS #11 hardware security module (HSM)
+
+    **cloudkms**
+    :  Use Google Cloud KMS
+
+    **azurekms**
+    :  Use Azure Key Vault
+
+    **awskms**
+    :  Use AWS KMS
+
+    **yubihsm**
+    :  Use a YubiHSM2
+
+    **sshagentkms**
+    :  Use keys stored in a running ssh-agent`,
+			},
+			cli.StringFlag{
+				Name:  "kms-uri",
+				Usage: `The <uri> with the kms configuration used to generate new keys.`,
+			},
+			cli.StringFlag{
+				Name:  "root-key-uri",
+				Usage: `The <uri> that identifies the root key on the KMS.`,
+			},
+			cli.StringFlag{
+				Name:  "intermediate-key-uri",
+				Usage: `The <uri> that identifies the intermediate key on the KMS.`,
+			},
+			cli.StringFlag{
+				Name:  "ssh-host-key-uri",
+				Usage: `The <uri> that identifies the SSH host key on the KMS. Only used with **--ssh**.`,
+			},
+			cli.StringFlag{
+				Name:  "ssh-user-key-uri",
+				Usage: `The <uri> that identifies the SSH user key on the KMS. Only used with **--ssh**.`,
+			},
+			cli.StringFlag{
+				Name:  "kms-credentials-file",
+				Usage: `The <file> with the credentials to authenticate to the KMS, required by some cloud KMSes.`,
+			},
+			cli.StringFlag{
+				Name:  "onboarding-token",
+				Usage: `The <token> used to bootstrap the CA from the onboarding service, skipping every prompt.`,
+			},
+			cli.StringFlag{
+				Name:  "onboarding-url",
+				Usage: `The <url> of the onboarding service to use with **--onboarding-token**.`,
+				Value: defaultOnboardingURL,
+			},
+			cli.StringFlag{
+				Name:  "config",
+				Usage: `The <file> with the onboarding parameters to use, read from disk instead of the onboarding service.`,
+			},
+			cli.StringFlag{
+				Name: "from-config",
+				Usage: `The <file> with a previously written pki-manifest.json to replay. The
+parameters recorded from the original **step ca init** run are read from this
+<file>, including the StepCAS issuer and Vault RA settings. CloudCAS settings,
+including its deployment type, are not recorded and are prompted for again,
+and **--root** and **--key** are not recorded either. Passwords and credentials
+files must still be supplied with **--password-file**,
+**--provisioner-password-file**, **--kms-credentials-file**,
+**--vault-token-file**, **--vault-role-id-file**, **--vault-secret-id-file**,
+**--vault-jwt-file**, or the corresponding environment variables.`,
+			},
+			cli.StringFlag{
+				Name:  "vault-pki-mount",
+				Usage: `The <path> of the Vault PKI secrets engine mount to use. Only used with **--ra=vault**.`,
+			},
+			cli.StringFlag{
+				Name:  "vault-pki-role",
+				Usage: `The <name> of the Vault PKI role used to issue certificates. Only used with **--ra=vault**.`,
+			},
+			cli.StringFlag{
+				Name: "vault-auth-method",
+				Usage: `The <name> of the Vault authentication method to use. Only used with **--ra=vault**. Options are:
+    **token**
+    :  Authenticate with a Vault token, read from **--vault-token-file**.
+
+    **approle**
+    :  Authenticate with an AppRole role_id/secret_id pair, read from
+    **--vault-role-id-file** and **--vault-secret-id-file**.
+
+    **kubernetes**
+    :  Authenticate with the Kubernetes service account token mounted in the pod.
+
+    **jwt**
+    :  Authenticate with a JWT, read from **--vault-jwt-file**.`,
+				Value: "token",
+			},
+			cli.StringFlag{
+				Name:  "vault-token-file",
+				Usage: `The <file> with the Vault token to use. Required when **--vault-auth-method=token**.`,
+			},
+			cli.StringFlag{
+				Name:  "vault-role-id-file",
+				Usage: `The <file> with the Vault AppRole role_id to use. Required when **--vault-auth-method=approle**.`,
+			},
+			cli.StringFlag{
+				Name:  "vault-secret-id-file",
+				Usage: `The <file> with the Vault AppRole secret_id to use. Required when **--vault-auth-method=approle**.`,
+			},
+			cli.StringFlag{
+				Name:  "vault-jwt-file",
+				Usage: `The <file> with the JWT to use. Required when **--vault-auth-method=jwt** or **--vault-auth-method=kubernetes**.`,
+			},
+			cli.StringSliceFlag{
+				Name: "with-scep",
+				Usage: `Add a SCEP provisioner to the CA. Can be used multiple times. <value> is a
+comma separated list of key=value pairs:
+    **name**=<name>
+    :  The <name> of the provisioner. Defaults to "SCEP".
+
+    **challenge**=<challenge>
+    :  The shared secret clients must present when enrolling. If not set, you
+    will be prompted for one, or it can be read using **--scep-challenge-file**.
+    Required (inline or via **--scep-challenge-file**) with **--onboarding-token**
+    or **--config**, since prompts are skipped.
+
+    **capabilities**=<cap1>|<cap2>
+    :  A "|" separated list of SCEP capabilities. Defaults to "SHA-256|AES".
+
+    **minimumPublicKeyLength**=<bits>
+    :  The minimum public key length SCEP will accept. Defaults to 2048.
+
+: With **--helm**, the resulting SCEP provisioner's HTTP endpoint is exposed
+in the generated Helm values.`,
+			},
+			cli.StringFlag{
+				Name:  "scep-challenge-file",
+				Usage: `The <file> with the shared challenge password for provisioners added with **--with-scep**.`,
+			},
+			cli.StringSliceFlag{
+				Name: "with-acme",
+				Usage: `Add an ACME provisioner to the CA. Can be used multiple times. <value> is a
+comma separated list of key=value pairs:
+    **name**=<name>
+    :  The <name> of the provisioner. Defaults to "ACME".
+
+    **forceCN**=<bool>
+    :  Force one of the SANs to become the Common Name, if a Common Name is not provided.
+
+: With **--helm**, the resulting ACME provisioner's HTTP endpoint is exposed
+in the generated Helm values.`,
+			},
 		},
 	}
 }
 
+// defaultSCEPCapabilities and defaultSCEPMinimumPublicKeyLength are the SCEP
+// provisioner settings step-ca clients expect, used unless a --with-scep
+// value overrides them.
+var defaultSCEPCapabilities = []string{"SHA-256", "AES"}
+
+const defaultSCEPMinimumPublicKeyLength = 2048
+
+// scepProvisioner is a SCEP provisioner requested with --with-scep.
+type scepProvisioner struct {
+	Name                   string
+	Challenge              string
+	Capabilities           []string
+	MinimumPublicKeyLength int
+}
+
+// acmeProvisioner is an ACME provisioner requested with --with-acme.
+type acmeProvisioner struct {
+	Name    string
+	ForceCN bool
+}
+
+// parseKeyValue splits a "key=value" pair, trimming whitespace.
+func parseKeyValue(ctx *cli.Context, flag, kv string) (string, string, error) {
+	parts := strings.SplitN(kv, "=", 2)
+	if len(parts) != 2 {
+		return "", "", errs.InvalidFlagValue(ctx, flag, kv, "key=value")
+	}
+	return strings.ToLower(strings.TrimSpace(parts[0])), strings.TrimSpace(parts[1]), nil
+}
+
+// parseSCEPProvisioners parses the repeatable --with-scep values (either
+// given directly on the command line or replayed from pki-manifest.json)
+// into a list of SCEP provisioners to add to the generated ca.json.
+func parseSCEPProvisioners(ctx *cli.Context, values []string) ([]scepProvisioner, error) {
+	var provisioners []scepProvisioner
+	for _, v := range values {
+		p := scepProvisioner{
+			Name:                   "SCEP",
+			Capabilities:           defaultSCEPCapabilities,
+			MinimumPublicKeyLength: defaultSCEPMinimumPublicKeyLength,
+		}
+		for _, kv := range strings.Split(v, ",") {
+			if kv == "" {
+				continue
+			}
+			key, value, err := parseKeyValue(ctx, "with-scep", kv)
+			if err != nil {
+				return nil, err
+			}
+			switch key {
+			case "name":
+				p.Name = value
+			case "challenge":
+				p.Challenge = value
+			case "capabilities":
+				p.Capabilities = strings.Split(value, "|")
+			case "minimumpublickeylength":
+				length, err := strconv.Atoi(value)
+				if err != nil {
+					return nil, errs.InvalidFlagValue(ctx, "with-scep", v, "name=<name>,challenge=<challenge>,capabilities=<cap1>|<cap2>,minimumPublicKeyLength=<int>")
+				}
+				p.MinimumPublicKeyLength = length
+			default:
+				return nil, errs.InvalidFlagValue(ctx, "with-scep", v, "name=<name>,challenge=<challenge>,capabilities=<cap1>|<cap2>,minimumPublicKeyLength=<int>")
+			}
+		}
+		provisioners = append(provisioners, p)
+	}
+	return provisioners, nil
+}
+
+// parseACMEProvisioners parses the repeatable --with-acme values (either
+// given directly on the command line or replayed from pki-manifest.json)
+// into a list of ACME provisioners to add to the generated ca.json.
+func parseACMEProvisioners(ctx *cli.Context, values []string) ([]acmeProvisioner, error) {
+	var provisioners []acmeProvisioner
+	for _, v := range values {
+		p := acmeProvisioner{Name: "ACME"}
+		for _, kv := range strings.Split(v, ",") {
+			if kv == "" {
+				continue
+			}
+			key, value, err := parseKeyValue(ctx, "with-acme", kv)
+			if err != nil {
+				return nil, err
+			}
+			switch key {
+			case "name":
+				p.Name = value
+			case "forcecn":
+				forceCN, err := strconv.ParseBool(value)
+				if err != nil {
+					return nil, errs.InvalidFlagValue(ctx, "with-acme", v, "name=<name>,forceCN=<bool>")
+				}
+				p.ForceCN = forceCN
+			default:
+				return nil, errs.InvalidFlagValue(ctx, "with-acme", v, "name=<name>,forceCN=<bool>")
+			}
+		}
+		provisioners = append(provisioners, p)
+	}
+	return provisioners, nil
+}
+
 func initAction(ctx *cli.Context) (err error) {
 	if err = assertCryptoRand(); err != nil {
 		return err
@@ -151,19 +689,66 @@ func initAction(ctx *cli.Context) (err error) {
 	var rootCrt *x509.Certificate
 	var rootKey interface{}
 
-	caURL := ctx.String("with-ca-url")
+	var manifest *pkiManifest
+	if fromConfig := ctx.String("from-config"); fromConfig != "" {
+		manifest, err = readPKIManifest(fromConfig)
+		if err != nil {
+			return err
+		}
+	}
+
+	caURL, err := flagOrManifest(ctx, "with-ca-url", manifestGet(manifest, func(m *pkiManifest) string { return m.WithCaURL }))
+	if err != nil {
+		return err
+	}
 	root := ctx.String("root")
 	key := ctx.String("key")
-	ra := strings.ToLower(ctx.String("ra"))
+	raValue, err := flagOrManifest(ctx, "ra", manifestGet(manifest, func(m *pkiManifest) string { return m.RA }))
+	if err != nil {
+		return err
+	}
+	ra := strings.ToLower(raValue)
+	if ra == "vault" {
+		// "vault" is a documented, friendlier alias for apiv1.VaultCAS.
+		ra = apiv1.VaultCAS
+	}
 	pkiOnly := ctx.Bool("pki")
-	noDB := ctx.Bool("no-db")
-	helm := ctx.Bool("helm")
+	noDB := ctx.Bool("no-db") || (manifest != nil && manifest.NoDB && !ctx.IsSet("no-db"))
+	helm := ctx.Bool("helm") || (manifest != nil && manifest.Helm && !ctx.IsSet("helm"))
+	kmsValue, err := flagOrManifest(ctx, "kms", manifestGet(manifest, func(m *pkiManifest) string { return m.KMS }))
+	if err != nil {
+		return err
+	}
+	kmsType := strings.ToLower(kmsValue)
+	onboardingToken := ctx.String("onboarding-token")
+	configFile := ctx.String("config")
+	sshEnabled := ctx.Bool("ssh") || (manifest != nil && manifest.SSH && !ctx.IsSet("ssh"))
 
 	switch {
+	case manifest != nil && (onboardingToken != "" || configFile != ""):
+		return errs.IncompatibleFlagWithFlag(ctx, "from-config", "onboarding-token")
+	case manifest != nil && pkiOnly:
+		return errs.IncompatibleFlagWithFlag(ctx, "from-config", "pki")
+	case onboardingToken != "" && configFile != "":
+		return errs.IncompatibleFlagWithFlag(ctx, "onboarding-token", "config")
+	case (onboardingToken != "" || configFile != "") && ra != "":
+		return errs.IncompatibleFlagWithFlag(ctx, "onboarding-token", "ra")
+	case (onboardingToken != "" || configFile != "") && pkiOnly:
+		return errs.IncompatibleFlagWithFlag(ctx, "onboarding-token", "pki")
 	case len(root) > 0 && len(key) == 0:
 		return errs.RequiredWithFlag(ctx, "root", "key")
 	case len(root) == 0 && len(key) > 0:
 		return errs.RequiredWithFlag(ctx, "key", "root")
+	case kmsType != "" && !isValidKMS(kmsType):
+		return errs.InvalidFlagValue(ctx, "kms", kmsType, strings.Join(kmsTypes, ", "))
+	case kmsType != "" && len(root) > 0:
+		return errs.IncompatibleFlagWithFlag(ctx, "kms", "root")
+	case kmsType != "" && len(key) > 0:
+		return errs.IncompatibleFlagWithFlag(ctx, "kms", "key")
+	case kmsType != "" && ra != "":
+		// Every RA mode manages its own key material (in the RA itself or,
+		// for VaultCAS, in Vault), so none of them use KMS-backed local keys.
+		return errs.IncompatibleFlagWithFlag(ctx, "kms", "ra")
 	case len(root) > 0 && len(key) > 0:
 		if rootCrt, err = pemutil.ReadCertificate(root); err != nil {
 			return err
@@ -171,12 +756,127 @@ func initAction(ctx *cli.Context) (err error) {
 		if rootKey, err = pemutil.Read(key); err != nil {
 			return err
 		}
-	case ra != "" && ra != apiv1.CloudCAS && ra != apiv1.StepCAS:
-		return errs.InvalidFlagValue(ctx, "ra", ctx.String("ra"), "StepCAS or CloudCAS")
+	case ra != "" && ra != apiv1.CloudCAS && ra != apiv1.StepCAS && ra != apiv1.VaultCAS:
+		return errs.InvalidFlagValue(ctx, "ra", ctx.String("ra"), "StepCAS, CloudCAS, VaultCAS or vault")
+	case ra == apiv1.VaultCAS && sshEnabled:
+		return errs.IncompatibleFlagWithFlag(ctx, "ra", "ssh")
+	case ra != apiv1.VaultCAS && ctx.IsSet("vault-auth-method") && ctx.String("vault-auth-method") != "token":
+		return errs.RequiredWithFlag(ctx, "vault-auth-method", "ra")
+	case ra == apiv1.VaultCAS && !isValidVaultAuthMethod(strings.ToLower(ctx.String("vault-auth-method"))):
+		return errs.InvalidFlagValue(ctx, "vault-auth-method", ctx.String("vault-auth-method"), "token, approle, kubernetes or jwt")
 	case pkiOnly && noDB:
 		return errs.IncompatibleFlagWithFlag(ctx, "pki", "no-db")
 	case pkiOnly && helm:
 		return errs.IncompatibleFlagWithFlag(ctx, "pki", "helm")
+	case pkiOnly && len(ctx.StringSlice("with-scep")) > 0:
+		return errs.IncompatibleFlagWithFlag(ctx, "pki", "with-scep")
+	case pkiOnly && len(ctx.StringSlice("with-acme")) > 0:
+		return errs.IncompatibleFlagWithFlag(ctx, "pki", "with-acme")
+	case kmsType == "" && ctx.String("ssh-host-key-uri") != "":
+		return errs.RequiredWithFlag(ctx, "ssh-host-key-uri", "kms")
+	case kmsType == "" && ctx.String("ssh-user-key-uri") != "":
+		return errs.RequiredWithFlag(ctx, "ssh-user-key-uri", "kms")
+	}
+
+	scepValues := stringSliceOrManifest(ctx, "with-scep", manifestGetSlice(manifest, func(m *pkiManifest) []string { return m.SCEPProvisioners }))
+	acmeValues := stringSliceOrManifest(ctx, "with-acme", manifestGetSlice(manifest, func(m *pkiManifest) []string { return m.ACMEProvisioners }))
+	scepProvisioners, err := parseSCEPProvisioners(ctx, scepValues)
+	if err != nil {
+		return err
+	}
+	acmeProvisioners, err := parseACMEProvisioners(ctx, acmeValues)
+	if err != nil {
+		return err
+	}
+	if len(scepProvisioners) > 0 {
+		var challenge string
+		if challengeFile := ctx.String("scep-challenge-file"); challengeFile != "" {
+			b, err := utils.ReadPasswordFromFile(challengeFile)
+			if err != nil {
+				return err
+			}
+			challenge = string(b)
+		}
+		for i := range scepProvisioners {
+			if scepProvisioners[i].Challenge != "" {
+				continue
+			}
+			if challenge == "" {
+				if onboardingToken != "" || configFile != "" || manifest != nil {
+					return errs.NewError("the %q SCEP provisioner needs a challenge; set %q inline in --with-scep or use --scep-challenge-file, since prompts are skipped with --onboarding-token/--config/--from-config", scepProvisioners[i].Name, "challenge=")
+				}
+				ui.Println("Choose a challenge password for the SCEP provisioner.")
+				challenge, err = ui.PromptPasswordGenerate("[leave empty and we'll generate one]", ui.WithRichPrompt())
+				if err != nil {
+					return err
+				}
+			}
+			scepProvisioners[i].Challenge = challenge
+		}
+	}
+
+	// Resolve the onboarding payload driving an unattended init, either
+	// fetched from the onboarding service or read from a local file. Both
+	// paths use the same versioned schema and skip every interactive prompt
+	// below.
+	var onboarding *onboardingPayload
+	switch {
+	case onboardingToken != "":
+		onboarding, err = fetchOnboardingPayload(ctx.String("onboarding-url"), onboardingToken)
+		if err != nil {
+			return err
+		}
+	case configFile != "":
+		onboarding, err = readOnboardingPayload(configFile)
+		if err != nil {
+			return err
+		}
+	}
+	if onboarding != nil && onboarding.SSH != nil && !ctx.IsSet("ssh") {
+		sshEnabled = *onboarding.SSH
+	}
+
+	// A replayed pki-manifest.json drives the same non-interactive flow as
+	// an onboarding payload for the default (standalone) deployment.
+	if manifest != nil && onboarding == nil {
+		onboarding = &onboardingPayload{
+			Name:           manifest.Name,
+			DNS:            manifest.DNSNames,
+			Address:        manifest.Address,
+			DeploymentType: manifest.DeploymentType,
+		}
+		if manifest.SSH {
+			onboarding.SSH = &manifest.SSH
+		}
+		if manifest.Provisioner != "" {
+			onboarding.Provisioner = &onboardingProvisioner{Name: manifest.Provisioner}
+		}
+	}
+
+	// When no KMS flags are given, offer KMS-backed keys as an alternative to
+	// on-disk keys, unless the root/key or an RA is already driving key
+	// material.
+	if onboarding == nil && kmsType == "" && root == "" && key == "" && ra == "" && !pkiOnly {
+		useKMS, err := ui.PromptYesNo("Would you like to generate your keys in a KMS (y) or on disk (n)?")
+		if err != nil {
+			return err
+		}
+		if useKMS {
+			type kmsOption struct {
+				Name  string
+				Value string
+			}
+			kmsOptions := make([]kmsOption, len(kmsTypes))
+			for i, t := range kmsTypes {
+				kmsOptions[i] = kmsOption{Name: t, Value: t}
+			}
+			i, _, err := ui.Select("What kms would you like to use?", kmsOptions,
+				ui.WithSelectTemplates(ui.NamedSelectTemplates("KMS")))
+			if err != nil {
+				return err
+			}
+			kmsType = kmsOptions[i].Value
+		}
 	}
 
 	var password string
@@ -200,6 +900,8 @@ func initAction(ctx *cli.Context) (err error) {
 	// Common for both CA and RA
 
 	var name, org, resource string
+	var raIssuer, raIssuerFingerprint, raIssuerProvisioner string
+	var raVaultMount, raVaultRole, raVaultAuthMethod string
 	var casOptions apiv1.Options
 	var deploymentType pki.DeploymentType
 	switch ra {
@@ -292,28 +994,45 @@ func initAction(ctx *cli.Context) (err error) {
 			GCSBucket:            gcsBucket,
 		}
 	case apiv1.StepCAS:
-		deploymentType, err = promptDeploymentType(ctx, true)
+		deploymentType, err = resolveDeploymentType(ctx, manifest, true)
 		if err != nil {
 			return err
 		}
-		ui.Println("What is the url of your CA?", ui.WithValue(ctx.String("issuer")))
-		ca, err := ui.Prompt("(e.g. https://ca.smallstep.com:9000)",
-			ui.WithValidateRegexp("(?i)^https://.+$"), ui.WithValue(ctx.String("issuer")))
+		ca, err := flagOrManifest(ctx, "issuer", manifestGet(manifest, func(m *pkiManifest) string { return m.Issuer }))
 		if err != nil {
 			return err
 		}
-		ui.Println("What is the fingerprint of the CA's root file?", ui.WithValue(ctx.String("issuer-fingerprint")))
-		fingerprint, err := ui.Prompt("(e.g. 4fe5f5ef09e95c803fdcb80b8cf511e2a885eb86f3ce74e3e90e62fa3faf1531)",
-			ui.WithValidateRegexp("^[a-fA-F0-9]{64}$"), ui.WithValue(ctx.String("issuer-fingerprint")))
+		if ca == "" {
+			ui.Println("What is the url of your CA?")
+			ca, err = ui.Prompt("(e.g. https://ca.smallstep.com:9000)", ui.WithValidateRegexp("(?i)^https://.+$"))
+			if err != nil {
+				return err
+			}
+		}
+		fingerprint, err := flagOrManifest(ctx, "issuer-fingerprint", manifestGet(manifest, func(m *pkiManifest) string { return m.IssuerFingerprint }))
 		if err != nil {
 			return err
 		}
-		ui.Println("What is the JWK provisioner you want to use?", ui.WithValue(ctx.String("issuer-provisioner")))
-		provisioner, err := ui.Prompt("(e.g. you@smallstep.com)",
-			ui.WithValidateNotEmpty(), ui.WithValue(ctx.String("issuer-provisioner")))
+		if fingerprint == "" {
+			ui.Println("What is the fingerprint of the CA's root file?")
+			fingerprint, err = ui.Prompt("(e.g. 4fe5f5ef09e95c803fdcb80b8cf511e2a885eb86f3ce74e3e90e62fa3faf1531)",
+				ui.WithValidateRegexp("^[a-fA-F0-9]{64}$"))
+			if err != nil {
+				return err
+			}
+		}
+		provisioner, err := flagOrManifest(ctx, "issuer-provisioner", manifestGet(manifest, func(m *pkiManifest) string { return m.IssuerProvisioner }))
 		if err != nil {
 			return err
 		}
+		if provisioner == "" {
+			ui.Println("What is the JWK provisioner you want to use?")
+			provisioner, err = ui.Prompt("(e.g. you@smallstep.com)", ui.WithValidateNotEmpty())
+			if err != nil {
+				return err
+			}
+		}
+		raIssuer, raIssuerFingerprint, raIssuerProvisioner = ca, fingerprint, provisioner
 		casOptions = apiv1.Options{
 			Type:                            apiv1.StepCAS,
 			IsCreator:                       false,
@@ -325,11 +1044,96 @@ func initAction(ctx *cli.Context) (err error) {
 				Provisioner: provisioner,
 			},
 		}
-	default:
-		deploymentType, err = promptDeploymentType(ctx, false)
+	case apiv1.VaultCAS:
+		deploymentType, err = resolveDeploymentType(ctx, manifest, true)
+		if err != nil {
+			return err
+		}
+		address, err := flagOrManifest(ctx, "issuer", manifestGet(manifest, func(m *pkiManifest) string { return m.Issuer }))
 		if err != nil {
 			return err
 		}
+		if address == "" {
+			ui.Println("What is the address of your Vault server?")
+			address, err = ui.Prompt("(e.g. https://vault.example.com)", ui.WithValidateRegexp("(?i)^https?://.+$"))
+			if err != nil {
+				return err
+			}
+		}
+		mount, err := flagOrManifest(ctx, "vault-pki-mount", manifestGet(manifest, func(m *pkiManifest) string { return m.VaultPKIMount }))
+		if err != nil {
+			return err
+		}
+		if mount == "" {
+			ui.Println("What is the path of the PKI secrets engine mount?")
+			mount, err = ui.Prompt("(e.g. pki)", ui.WithValidateNotEmpty())
+			if err != nil {
+				return err
+			}
+		}
+		role, err := flagOrManifest(ctx, "vault-pki-role", manifestGet(manifest, func(m *pkiManifest) string { return m.VaultPKIRole }))
+		if err != nil {
+			return err
+		}
+		if role == "" {
+			ui.Println("What is the name of the PKI role to use?")
+			role, err = ui.Prompt("(e.g. step-ca)", ui.WithValidateNotEmpty())
+			if err != nil {
+				return err
+			}
+		}
+
+		authMethodValue, err := flagOrManifest(ctx, "vault-auth-method", manifestGet(manifest, func(m *pkiManifest) string { return m.VaultAuthMethod }))
+		if err != nil {
+			return err
+		}
+		authMethod := strings.ToLower(authMethodValue)
+		raIssuer, raVaultMount, raVaultRole, raVaultAuthMethod = address, mount, role, authMethod
+		vaultOptions := &apiv1.VaultOptions{
+			PKIMount:   mount,
+			PKIRole:    role,
+			AuthMethod: authMethod,
+		}
+		switch authMethod {
+		case "token":
+			if vaultOptions.TokenFile, err = promptOrFlag(ctx, "vault-token-file", "What is the file with your Vault token?", "(e.g. /home/user/.vault-token)"); err != nil {
+				return err
+			}
+		case "approle":
+			if vaultOptions.RoleIDFile, err = promptOrFlag(ctx, "vault-role-id-file", "What is the file with your AppRole role_id?", "(e.g. /home/user/role_id)"); err != nil {
+				return err
+			}
+			if vaultOptions.SecretIDFile, err = promptOrFlag(ctx, "vault-secret-id-file", "What is the file with your AppRole secret_id?", "(e.g. /home/user/secret_id)"); err != nil {
+				return err
+			}
+		case "jwt", "kubernetes":
+			if vaultOptions.JWTFile, err = promptOrFlag(ctx, "vault-jwt-file", "What is the file with your JWT?", "(e.g. /var/run/secrets/kubernetes.io/serviceaccount/token)"); err != nil {
+				return err
+			}
+		}
+
+		casOptions = apiv1.Options{
+			Type:                 apiv1.VaultCAS,
+			CertificateAuthority: address,
+			IsCreator:            false,
+			IsCAGetter:           true,
+			Vault:                vaultOptions,
+		}
+	default:
+		if onboarding != nil {
+			deploymentType = pki.StandaloneDeployment
+			if onboarding.DeploymentType != "" {
+				deploymentType, err = parseDeploymentType(ctx, onboarding.DeploymentType)
+				if err != nil {
+					return err
+				}
+			}
+		} else {
+			deploymentType, err = promptDeploymentType(ctx, false)
+			if err != nil {
+				return err
+			}
+		}
 		if deploymentType == pki.HostedDeployment {
 			ui.Println()
 			ui.Println("The initialization of a hosted deployment is not yet supported by this tool.")
@@ -340,12 +1144,19 @@ func initAction(ctx *cli.Context) (err error) {
 			return nil
 		}
 
-		ui.Println("What would you like to name your new PKI?", ui.WithValue(ctx.String("name")))
-		name, err = ui.Prompt("(e.g. Smallstep)", ui.WithValidateNotEmpty(), ui.WithValue(ctx.String("name")))
-		if err != nil {
-			return err
+		if onboarding != nil {
+			name = onboarding.Name
+		} else {
+			ui.Println("What would you like to name your new PKI?", ui.WithValue(ctx.String("name")))
+			name, err = ui.Prompt("(e.g. Smallstep)", ui.WithValidateNotEmpty(), ui.WithValue(ctx.String("name")))
+			if err != nil {
+				return err
+			}
 		}
 		org = name
+		if manifest != nil && manifest.Organization != "" {
+			org = manifest.Organization
+		}
 		casOptions = apiv1.Options{
 			Type:      apiv1.SoftCAS,
 			IsCreator: true,
@@ -353,45 +1164,53 @@ func initAction(ctx *cli.Context) (err error) {
 	}
 
 	var opts []pki.PKIOption
+	var dnsNames []string
+	var address string
+	var provisioner string
 	if pkiOnly {
 		opts = append(opts, pki.WithPKIOnly())
 	} else {
-		var names string
-		ui.Println("What DNS names or IP addresses would you like to add to your new CA?", ui.WithValue(ctx.String("dns")))
-		names, err = ui.Prompt("(e.g. ca.smallstep.com[,1.1.1.1,etc.])",
-			ui.WithValidateFunc(ui.DNS()), ui.WithValue(ctx.String("dns")))
-		if err != nil {
-			return err
-		}
-		names = strings.Replace(names, " ", ",", -1)
-		parts := strings.Split(names, ",")
-		var dnsNames []string
-		for _, name := range parts {
-			if len(name) == 0 {
-				continue
+		if onboarding != nil {
+			dnsNames = splitDNSNames(onboarding.DNS)
+			address = onboarding.Address
+			if deploymentType == pki.StandaloneDeployment {
+				switch {
+				case onboarding.Provisioner != nil && onboarding.Provisioner.Name != "":
+					provisioner = onboarding.Provisioner.Name
+				case onboarding.Provisioner != nil && onboarding.Provisioner.Email != "":
+					provisioner = onboarding.Provisioner.Email
+				default:
+					provisioner = name
+				}
 			}
-			dnsNames = append(dnsNames, strings.TrimSpace(name))
-		}
-
-		var address string
-		ui.Println("What IP and port will your new CA bind to?", ui.WithValue(ctx.String("address")))
-		address, err = ui.Prompt("(e.g. :443 or 127.0.0.1:4343)",
-			ui.WithValidateFunc(ui.Address()), ui.WithValue(ctx.String("address")))
-		if err != nil {
-			return err
-		}
+		} else {
+			var names string
+			ui.Println("What DNS names or IP addresses would you like to add to your new CA?", ui.WithValue(ctx.String("dns")))
+			names, err = ui.Prompt("(e.g. ca.smallstep.com[,1.1.1.1,etc.])",
+				ui.WithValidateFunc(ui.DNS()), ui.WithValue(ctx.String("dns")))
+			if err != nil {
+				return err
+			}
+			dnsNames = splitDNSNames(names)
 
-		var provisioner string
-		// Only standalone deployments with create an initial provisioner.
-		// Linked or hosted deployments will use an OIDC token as the first
-		// deployment.
-		if deploymentType == pki.StandaloneDeployment {
-			ui.Println("What would you like to name the CA's first provisioner?", ui.WithValue(ctx.String("provisioner")))
-			provisioner, err = ui.Prompt("(e.g. you@smallstep.com)",
-				ui.WithValidateNotEmpty(), ui.WithValue(ctx.String("provisioner")))
+			ui.Println("What IP and port will your new CA bind to?", ui.WithValue(ctx.String("address")))
+			address, err = ui.Prompt("(e.g. :443 or 127.0.0.1:4343)",
+				ui.WithValidateFunc(ui.Address()), ui.WithValue(ctx.String("address")))
 			if err != nil {
 				return err
 			}
+
+			// Only standalone deployments with create an initial provisioner.
+			// Linked or hosted deployments will use an OIDC token as the first
+			// deployment.
+			if deploymentType == pki.StandaloneDeployment {
+				ui.Println("What would you like to name the CA's first provisioner?", ui.WithValue(ctx.String("provisioner")))
+				provisioner, err = ui.Prompt("(e.g. you@smallstep.com)",
+					ui.WithValidateNotEmpty(), ui.WithValue(ctx.String("provisioner")))
+				if err != nil {
+					return err
+				}
+			}
 		}
 
 		opts = []pki.PKIOption{
@@ -405,22 +1224,74 @@ func initAction(ctx *cli.Context) (err error) {
 		}
 		if deploymentType == pki.LinkedDeployment {
 			opts = append(opts, pki.WithAdmin())
-		} else if ctx.Bool("ssh") {
+		} else if sshEnabled {
 			opts = append(opts, pki.WithSSH())
 		}
-		if noDB {
+		if noDB || ra == apiv1.VaultCAS {
 			opts = append(opts, pki.WithNoDB())
 		}
+		for _, sp := range scepProvisioners {
+			opts = append(opts, pki.WithSCEPProvisioner(sp.Name, sp.Challenge, sp.Capabilities, sp.MinimumPublicKeyLength))
+		}
+		for _, ap := range acmeProvisioners {
+			opts = append(opts, pki.WithACMEProvisioner(ap.Name, ap.ForceCN))
+		}
 		if helm {
 			opts = append(opts, pki.WithHelm())
 		}
 	}
 
+	kmsURI, err := flagOrManifest(ctx, "kms-uri", manifestGet(manifest, func(m *pkiManifest) string { return m.KMSURI }))
+	if err != nil {
+		return err
+	}
+	rootKeyURI, err := flagOrManifest(ctx, "root-key-uri", manifestGet(manifest, func(m *pkiManifest) string { return m.RootKeyURI }))
+	if err != nil {
+		return err
+	}
+	intermediateKeyURI, err := flagOrManifest(ctx, "intermediate-key-uri", manifestGet(manifest, func(m *pkiManifest) string { return m.IntermediateKeyURI }))
+	if err != nil {
+		return err
+	}
+	sshHostKeyURI, err := flagOrManifest(ctx, "ssh-host-key-uri", manifestGet(manifest, func(m *pkiManifest) string { return m.SSHHostKeyURI }))
+	if err != nil {
+		return err
+	}
+	sshUserKeyURI, err := flagOrManifest(ctx, "ssh-user-key-uri", manifestGet(manifest, func(m *pkiManifest) string { return m.SSHUserKeyURI }))
+	if err != nil {
+		return err
+	}
+
+	if kmsType != "" {
+		opts = append(opts, pki.WithKMS(kmsapi.Type(kmsType), kmsURI))
+		if rootKeyURI != "" {
+			opts = append(opts, pki.WithRootKeyURI(rootKeyURI))
+		}
+		if intermediateKeyURI != "" {
+			opts = append(opts, pki.WithIntermediateKeyURI(intermediateKeyURI))
+		}
+		if sshEnabled {
+			if sshHostKeyURI != "" {
+				opts = append(opts, pki.WithSSHHostKeyURI(sshHostKeyURI))
+			}
+			if sshUserKeyURI != "" {
+				opts = append(opts, pki.WithSSHUserKeyURI(sshUserKeyURI))
+			}
+		}
+		if v := ctx.String("kms-credentials-file"); v != "" {
+			opts = append(opts, pki.WithKMSCredentialsFile(v))
+		}
+	}
+
 	p, err := pki.New(casOptions, opts...)
 	if err != nil {
 		return err
 	}
 
+	if password == "" && (onboardingToken != "" || configFile != "") {
+		return errs.NewError("a CA key password is required with --onboarding-token or --config, since prompts are skipped; set %q", "--password-file")
+	}
+
 	// Linked CAs will use OIDC as a first provisioner.
 	if pkiOnly || deploymentType != pki.StandaloneDeployment {
 		ui.Println("Choose a password for your CA keys.", ui.WithValue(password))
@@ -434,7 +1305,10 @@ func initAction(ctx *cli.Context) (err error) {
 	}
 
 	if !pkiOnly && deploymentType == pki.StandaloneDeployment {
-		// Generate provisioner key pairs.
+		// Generate provisioner key pairs. This is also the point where any
+		// SCEP challenge registered above via WithSCEPProvisioner gets
+		// encrypted at rest with the same password, the same way the rest
+		// of the authority's provisioner secrets are.
 		if len(provisionerPassword) > 0 {
 			if err = p.GenerateKeyPairs(provisionerPassword); err != nil {
 				return err
@@ -475,6 +1349,14 @@ func initAction(ctx *cli.Context) (err error) {
 			return err
 		}
 		ui.Println("done!")
+
+		// Report the root fingerprint back to the onboarding service so a
+		// paired UI/CLI can complete setup.
+		if onboardingToken != "" && root.Certificate != nil {
+			if err := sendOnboardingFingerprint(ctx.String("onboarding-url"), onboardingToken, certificateFingerprint(root.Certificate)); err != nil {
+				return err
+			}
+		}
 	} else {
 		// Attempt to get the root certificate from RA.
 		if err := p.GetCertificateAuthority(); err != nil {
@@ -482,7 +1364,7 @@ func initAction(ctx *cli.Context) (err error) {
 		}
 	}
 
-	if ctx.Bool("ssh") {
+	if sshEnabled {
 		ui.Printf("Generating user and host SSH certificate signing keys... ")
 		if err := p.GenerateSSHSigningKeys(pass); err != nil {
 			return err
@@ -490,12 +1372,119 @@ func initAction(ctx *cli.Context) (err error) {
 		ui.Println("done!")
 	}
 
+	if !pkiOnly {
+		m := &pkiManifest{
+			DeploymentType:     deploymentTypeName(deploymentType),
+			Name:               name,
+			Organization:       org,
+			Resource:           resource,
+			DNSNames:           strings.Join(dnsNames, ","),
+			Address:            address,
+			Provisioner:        provisioner,
+			SSH:                sshEnabled,
+			NoDB:               noDB,
+			Helm:               helm,
+			WithCaURL:          caURL,
+			RA:                 ra,
+			Issuer:             raIssuer,
+			IssuerFingerprint:  raIssuerFingerprint,
+			IssuerProvisioner:  raIssuerProvisioner,
+			VaultPKIMount:      raVaultMount,
+			VaultPKIRole:       raVaultRole,
+			VaultAuthMethod:    raVaultAuthMethod,
+			KMS:                kmsType,
+			KMSURI:             kmsURI,
+			RootKeyURI:         rootKeyURI,
+			IntermediateKeyURI: intermediateKeyURI,
+			SSHHostKeyURI:      sshHostKeyURI,
+			SSHUserKeyURI:      sshUserKeyURI,
+			SCEPProvisioners:   redactSCEPChallenge(scepValues),
+			ACMEProvisioners:   acmeValues,
+		}
+		if err := writePKIManifest(step.Path("config"), m); err != nil {
+			return err
+		}
+	}
+
 	if helm {
+		// p already has any --with-scep/--with-acme provisioners configured
+		// via WithSCEPProvisioner/WithACMEProvisioner above, the same way it
+		// already has --ssh and --no-db applied. WriteHelmTemplate renders
+		// the Helm values from that configuration, so the SCEP/ACME HTTP
+		// endpoints it writes come from the provisioners added above.
 		return p.WriteHelmTemplate(os.Stdout)
 	}
 	return p.Save()
 }
 
+// redactSCEPChallenge strips any challenge=... component out of --with-scep
+// values before they are recorded in pki-manifest.json, since the challenge
+// is a secret.
+func redactSCEPChallenge(values []string) []string {
+	redacted := make([]string, len(values))
+	for i, v := range values {
+		var kept []string
+		for _, kv := range strings.Split(v, ",") {
+			if k := strings.SplitN(kv, "=", 2)[0]; strings.EqualFold(strings.TrimSpace(k), "challenge") {
+				continue
+			}
+			kept = append(kept, kv)
+		}
+		redacted[i] = strings.Join(kept, ",")
+	}
+	return redacted
+}
+
+// deploymentTypeName converts a pki.DeploymentType back into the flag value
+// used by --deployment-type and the pki-manifest.json schema.
+func deploymentTypeName(d pki.DeploymentType) string {
+	switch d {
+	case pki.StandaloneDeployment:
+		return "standalone"
+	case pki.LinkedDeployment:
+		return "linked"
+	case pki.HostedDeployment:
+		return "hosted"
+	default:
+		return ""
+	}
+}
+
+// resolveDeploymentType returns the deployment type for an RA branch,
+// preferring an explicit --deployment-type flag, then a value replayed from
+// --from-config, and otherwise falling back to the interactive prompt.
+func resolveDeploymentType(ctx *cli.Context, manifest *pkiManifest, isRA bool) (pki.DeploymentType, error) {
+	if !ctx.IsSet("deployment-type") && manifest != nil && manifest.DeploymentType != "" {
+		return parseDeploymentType(ctx, manifest.DeploymentType)
+	}
+	return promptDeploymentType(ctx, isRA)
+}
+
+// parseDeploymentType converts the deployment type name used in the
+// onboarding schema into a pki.DeploymentType, without prompting.
+func parseDeploymentType(ctx *cli.Context, v string) (pki.DeploymentType, error) {
+	switch strings.ToLower(v) {
+	case "standalone":
+		return pki.StandaloneDeployment, nil
+	case "linked":
+		return pki.LinkedDeployment, nil
+	case "hosted":
+		return pki.HostedDeployment, nil
+	default:
+		return 0, errs.InvalidFlagValue(ctx, "deploymentType", v, "standalone, linked or hosted")
+	}
+}
+
+// promptOrFlag returns the value of the given flag if set, prompting for it
+// otherwise.
+func promptOrFlag(ctx *cli.Context, flag, prompt, example string) (string, error) {
+	if v := ctx.String(flag); v != "" {
+		return v, nil
+	}
+	ui.Println(prompt)
+	return ui.Prompt(example, ui.WithValidateNotEmpty())
+}
+
 func promptDeploymentType(ctx *cli.Context, isRA bool) (pki.DeploymentType, error) {
 	type deployment struct {
 		Name  string